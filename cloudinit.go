@@ -20,14 +20,12 @@
 package main
 
 import (
-	"bytes"
 	"context"
 	"encoding/base64"
 	"encoding/json"
 	"fmt"
 	"net"
 	"os"
-	"strings"
 
 	"google.golang.org/grpc"
 
@@ -61,7 +59,7 @@ func (s infoServer) Info(ctx context.Context, params *hooksInfo.InfoParams) (*ho
 
 type v1alpha2Server struct{}
 
-func getCloudInitData(params *hooksV1alpha2.PreCloudInitIsoParams) (*v1.CloudInitNoCloudSource, *v1.VirtualMachineInstance) {
+func getCloudInitData(params *hooksV1alpha2.PreCloudInitIsoParams) (cloudInitSource, *v1.VirtualMachineInstance) {
 	vmiJSON := params.GetVmi()
 	vmi := v1.VirtualMachineInstance{}
 	err := json.Unmarshal(vmiJSON, &vmi)
@@ -70,27 +68,22 @@ func getCloudInitData(params *hooksV1alpha2.PreCloudInitIsoParams) (*v1.CloudIni
 		panic(err)
 	}
 
-	cloudInitDataJSON := params.GetCloudInitData()
-	cloudInitData := v1.CloudInitNoCloudSource{}
-	err = json.Unmarshal(cloudInitDataJSON, &cloudInitData)
-	if err != nil {
-		log.Log.Reason(err).Errorf("Failed to unmarshal given CloudInitNoCloudSource: %s", cloudInitDataJSON)
-		panic(err)
-	}
-	return &cloudInitData, &vmi
+	cloudInitData := unmarshalCloudInitData(params.GetCloudInitData(), &vmi)
+	return cloudInitData, &vmi
 }
 
-func setUserData(cloudInitData *v1.CloudInitNoCloudSource) ([]byte, error) {
+func setUserData(cloudInitData cloudInitSource) ([]byte, error) {
 	var userData []byte
-	if cloudInitData.UserData != "" {
+	if cloudInitData.GetUserData() != "" {
 		log.Log.V(2).Info("Found UserData")
-		userData = []byte(cloudInitData.UserData)
-	} else if cloudInitData.UserDataBase64 != "" {
+		userData = []byte(cloudInitData.GetUserData())
+	} else if cloudInitData.GetUserDataBase64() != "" {
 		log.Log.V(2).Info("Found UserDataBase64")
-		userData, err := base64.StdEncoding.DecodeString(cloudInitData.UserDataBase64)
+		decoded, err := base64.StdEncoding.DecodeString(cloudInitData.GetUserDataBase64())
 		if err != nil {
-			return userData, err
+			return decoded, err
 		}
+		userData = decoded
 	}
 	if len(userData) == 0 {
 		log.Log.V(2).Info("no userData found: adding #cloud-config header")
@@ -100,31 +93,29 @@ func setUserData(cloudInitData *v1.CloudInitNoCloudSource) ([]byte, error) {
 	return userData, nil
 }
 
-func setAdditionalData(hostname string, resolvData, userData []byte) []byte {
+func setAdditionalData(vmi *v1.VirtualMachineInstance, hostname string, resolvData, userData []byte) []byte {
 	if len(resolvData) > 0 {
-		log.Log.V(2).Info("attempting to append resolvData to userData")
-		if strings.HasPrefix(string(userData), "#cloud-config") {
-			// Check if it already contains manage_resolv_conf
-			if bytes.Contains(userData, []byte("manage_resolv_conf:")) {
-				log.Log.V(2).Info("skipping append: manage_resolv_conf found in userData")
-			} else if len(resolvData) > 0 {
-				log.Log.V(2).Info("appending resolv configuration to userData")
-				userData = append(userData, []byte("\n")...)
-				userData = append(userData, resolvData...)
-			}
+		log.Log.V(2).Info("merging resolv configuration into userData")
+		merged, err := mergeCloudConfig(userData, resolvData)
+		if err != nil {
+			log.Log.Reason(err).Error("failed to merge resolv configuration into userData: skipping")
 		} else {
-			log.Log.V(2).Info("skipping append for resolvData: #cloud-config header not in userData ")
+			userData = merged
 		}
 	}
 
 	if len(HostsIpAddress) > 0 {
-		log.Log.V(2).Info("Attemping to append bootcmd for /etc/hosts to userData")
-		if strings.HasPrefix(string(userData), "#cloud-config") {
-			log.Log.V(2).Info("Appending bootcmd for /etc/hosts to userData")
-			bootStr := "bootcmd:\n  - cloud-init-per instance etcHosts sh -c \"echo " + HostsIpAddress + " " + hostname + " >> /etc/hosts\"\n"
-			userData = append(userData, []byte(bootStr)...)
-		} else {
-			log.Log.V(2).Info("skipping append for bootcmd: #cloud-config header not in userData ")
+		hostsData, err := buildHostsAdditions(vmi, hostname)
+		if err != nil {
+			log.Log.Reason(err).Error("failed to build /etc/hosts configuration: skipping")
+		} else if len(hostsData) > 0 {
+			log.Log.V(2).Info("merging /etc/hosts configuration into userData")
+			merged, err := mergeCloudConfig(userData, hostsData)
+			if err != nil {
+				log.Log.Reason(err).Error("failed to merge /etc/hosts configuration into userData: skipping")
+			} else {
+				userData = merged
+			}
 		}
 	}
 
@@ -134,11 +125,11 @@ func setAdditionalData(hostname string, resolvData, userData []byte) []byte {
 func (s v1alpha2Server) PreCloudInitIso(ctx context.Context, params *hooksV1alpha2.PreCloudInitIsoParams) (*hooksV1alpha2.PreCloudInitIsoResult, error) {
 	log.Log.Info("Hook's PreCloudInitIso callback method has been called")
 
-	var cloudInitData *v1.CloudInitNoCloudSource
+	var cloudInitData cloudInitSource
 	var vmi *v1.VirtualMachineInstance
 	cloudInitData, vmi = getCloudInitData(params)
 
-	if cloudInitData.NetworkData != "" || cloudInitData.NetworkDataBase64 != "" || cloudInitData.NetworkDataSecretRef != nil {
+	if cloudInitData.GetNetworkData() != "" || cloudInitData.GetNetworkDataBase64() != "" || cloudInitData.GetNetworkDataSecretRef() != nil {
 		log.Log.Warning("Skipping SR-IOV network discovery: cloud-init networkData is already defined")
 		return &hooksV1alpha2.PreCloudInitIsoResult{
 			CloudInitData: params.GetCloudInitData(),
@@ -158,17 +149,17 @@ func (s v1alpha2Server) PreCloudInitIso(ctx context.Context, params *hooksV1alph
 		}, err
 	}
 
-	userData = setAdditionalData(vmi.Spec.Hostname, resolvData, userData)
+	userData = setAdditionalData(vmi, vmi.Spec.Hostname, resolvData, userData)
 
-	cloudInitData.UserDataBase64 = base64.StdEncoding.EncodeToString([]byte(userData))
-	cloudInitData.NetworkDataBase64 = base64.StdEncoding.EncodeToString([]byte(networkData))
-	cloudInitData.UserData = ""
+	cloudInitData.SetUserDataBase64(base64.StdEncoding.EncodeToString([]byte(userData)))
+	cloudInitData.SetNetworkDataBase64(base64.StdEncoding.EncodeToString([]byte(networkData)))
+	cloudInitData.SetUserData("")
 
-	response, err := json.Marshal(cloudInitData)
+	response, err := cloudInitData.Marshal()
 	if err != nil {
 		return &hooksV1alpha2.PreCloudInitIsoResult{
 			CloudInitData: params.GetCloudInitData(),
-		}, fmt.Errorf("Failed to marshal CloudInitNoCloudSource: %v", cloudInitData)
+		}, fmt.Errorf("Failed to marshal cloud-init data: %v", cloudInitData)
 
 	}
 
@@ -0,0 +1,114 @@
+/*
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ *
+ * Copyright 2019 StackPath, LLC
+ *
+ */
+
+// Adds support for the ConfigDrive cloud-init datasource alongside NoCloud.
+// PreCloudInitIso previously assumed NoCloud unconditionally, so VMIs using
+// ConfigDrive (the standard datasource on many enterprise/OpenStack-derived
+// guest images) silently never got SR-IOV network injection.
+
+package main
+
+import (
+	"encoding/json"
+
+	v1 "kubevirt.io/kubevirt/pkg/api/v1"
+	"kubevirt.io/kubevirt/pkg/log"
+)
+
+// cloudInitSource abstracts over the NoCloud and ConfigDrive cloud-init
+// volume sources, which share the same user-data/network-data shape, so
+// PreCloudInitIso doesn't need to duplicate its discovery/merge logic per
+// datasource.
+type cloudInitSource interface {
+	GetUserData() string
+	GetUserDataBase64() string
+	GetNetworkData() string
+	GetNetworkDataBase64() string
+	GetNetworkDataSecretRef() *v1.LocalObjectReference
+	SetUserData(string)
+	SetUserDataBase64(string)
+	SetNetworkDataBase64(string)
+	Marshal() ([]byte, error)
+}
+
+type noCloudSource struct {
+	*v1.CloudInitNoCloudSource
+}
+
+func (s noCloudSource) GetUserData() string        { return s.UserData }
+func (s noCloudSource) GetUserDataBase64() string   { return s.UserDataBase64 }
+func (s noCloudSource) GetNetworkData() string      { return s.NetworkData }
+func (s noCloudSource) GetNetworkDataBase64() string { return s.NetworkDataBase64 }
+func (s noCloudSource) GetNetworkDataSecretRef() *v1.LocalObjectReference {
+	return s.NetworkDataSecretRef
+}
+func (s noCloudSource) SetUserData(data string)          { s.UserData = data }
+func (s noCloudSource) SetUserDataBase64(data string)     { s.UserDataBase64 = data }
+func (s noCloudSource) SetNetworkDataBase64(data string)  { s.NetworkDataBase64 = data }
+func (s noCloudSource) Marshal() ([]byte, error)          { return json.Marshal(s.CloudInitNoCloudSource) }
+
+type configDriveSource struct {
+	*v1.CloudInitConfigDriveSource
+}
+
+func (s configDriveSource) GetUserData() string            { return s.UserData }
+func (s configDriveSource) GetUserDataBase64() string       { return s.UserDataBase64 }
+func (s configDriveSource) GetNetworkData() string          { return s.NetworkData }
+func (s configDriveSource) GetNetworkDataBase64() string     { return s.NetworkDataBase64 }
+func (s configDriveSource) GetNetworkDataSecretRef() *v1.LocalObjectReference {
+	return s.NetworkDataSecretRef
+}
+func (s configDriveSource) SetUserData(data string)         { s.UserData = data }
+func (s configDriveSource) SetUserDataBase64(data string)    { s.UserDataBase64 = data }
+func (s configDriveSource) SetNetworkDataBase64(data string) { s.NetworkDataBase64 = data }
+func (s configDriveSource) Marshal() ([]byte, error) {
+	return json.Marshal(s.CloudInitConfigDriveSource)
+}
+
+// usesConfigDrive reports whether the VMI's cloud-init volume uses the
+// ConfigDrive datasource rather than NoCloud.
+func usesConfigDrive(vmi *v1.VirtualMachineInstance) bool {
+	for _, volume := range vmi.Spec.Volumes {
+		if volume.CloudInitConfigDrive != nil {
+			return true
+		}
+		if volume.CloudInitNoCloud != nil {
+			return false
+		}
+	}
+	return false
+}
+
+// unmarshalCloudInitData unmarshals the hook's raw cloud-init data into the
+// datasource type the VMI actually uses.
+func unmarshalCloudInitData(cloudInitDataJSON []byte, vmi *v1.VirtualMachineInstance) cloudInitSource {
+	if usesConfigDrive(vmi) {
+		data := v1.CloudInitConfigDriveSource{}
+		if err := json.Unmarshal(cloudInitDataJSON, &data); err != nil {
+			log.Log.Reason(err).Errorf("Failed to unmarshal given CloudInitConfigDriveSource: %s", cloudInitDataJSON)
+			panic(err)
+		}
+		return configDriveSource{&data}
+	}
+
+	data := v1.CloudInitNoCloudSource{}
+	if err := json.Unmarshal(cloudInitDataJSON, &data); err != nil {
+		log.Log.Reason(err).Errorf("Failed to unmarshal given CloudInitNoCloudSource: %s", cloudInitDataJSON)
+		panic(err)
+	}
+	return noCloudSource{&data}
+}
@@ -0,0 +1,155 @@
+/*
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ *
+ * Copyright 2019 StackPath, LLC
+ *
+ */
+
+// Adds support for operator-supplied static routes that aren't present in
+// the compute pod's own routing table but are still needed by the guest,
+// attached to a specific SR-IOV interface via a VMI annotation.
+
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"net"
+	"strings"
+
+	v1 "kubevirt.io/kubevirt/pkg/api/v1"
+	"kubevirt.io/kubevirt/pkg/log"
+)
+
+// customRoutesAnnotation holds a JSON array of customRoute objects, one per
+// operator-supplied static route.
+const customRoutesAnnotation = "sriov-hook.stackpath.com/routes"
+
+// customRoute is the JSON representation of an operator-supplied static
+// route for a specific SR-IOV interface, set via customRoutesAnnotation.
+type customRoute struct {
+	Iface  string `json:"iface"`
+	Dst    string `json:"dst"`
+	Gw     string `json:"gw"`
+	Metric int    `json:"metric"`
+}
+
+// getCustomRoutes parses the customRoutesAnnotation off of a VMI, returning
+// no routes if it isn't set.
+func getCustomRoutes(vmi *v1.VirtualMachineInstance) ([]customRoute, error) {
+	var routes []customRoute
+
+	raw, ok := vmi.Annotations[customRoutesAnnotation]
+	if !ok || raw == "" {
+		return routes, nil
+	}
+
+	if err := json.Unmarshal([]byte(raw), &routes); err != nil {
+		return nil, fmt.Errorf("failed to parse %s annotation: %v", customRoutesAnnotation, err)
+	}
+
+	return routes, nil
+}
+
+// customRoutesByIface groups custom routes by their target interface name.
+func customRoutesByIface(routes []customRoute) map[string][]customRoute {
+	byIface := map[string][]customRoute{}
+	for _, route := range routes {
+		byIface[route.Iface] = append(byIface[route.Iface], route)
+	}
+	return byIface
+}
+
+func customRouteToSubnetRoute(route customRoute) (CloudInitSubnetRoute, error) {
+	_, dst, err := net.ParseCIDR(route.Dst)
+	if err != nil {
+		return CloudInitSubnetRoute{}, fmt.Errorf("failed to parse dst %q for custom route on %s: %v", route.Dst, route.Iface, err)
+	}
+
+	return CloudInitSubnetRoute{
+		Network: dst.IP.String(),
+		Netmask: net.IP(dst.Mask).String(),
+		Gateway: route.Gw,
+	}, nil
+}
+
+// mergeCustomRoutesIntoNetConfig merges operator-supplied static routes into
+// the v1 cloud-init subnets of the matching interfaces, alongside the routes
+// already discovered from the VF's routing table.
+func mergeCustomRoutesIntoNetConfig(config *CloudInitNetConfig, routes []customRoute) error {
+	byIface := customRoutesByIface(routes)
+	if len(byIface) == 0 {
+		return nil
+	}
+
+	for i := range config.Config {
+		nif := &config.Config[i]
+		ifaceRoutes, ok := byIface[nif.Name]
+		if !ok {
+			continue
+		}
+
+		for _, route := range ifaceRoutes {
+			subnetRoute, err := customRouteToSubnetRoute(route)
+			if err != nil {
+				return err
+			}
+
+			isV6 := strings.Contains(route.Dst, ":")
+			matched := false
+			for j := range nif.Subnets {
+				if isV6 == strings.Contains(nif.Subnets[j].SubnetType, "6") {
+					nif.Subnets[j].Routes = append(nif.Subnets[j].Routes, subnetRoute)
+					matched = true
+					break
+				}
+			}
+
+			if !matched {
+				log.Log.Warningf("custom route %s on %s has no matching discovered subnet: adding a manual subnet for it", route.Dst, nif.Name)
+				nif.Subnets = append(nif.Subnets, CloudInitSubnet{
+					SubnetType: "manual",
+					Routes:     []CloudInitSubnetRoute{subnetRoute},
+				})
+			}
+		}
+	}
+
+	return nil
+}
+
+// mergeCustomRoutesIntoNetplanConfig merges operator-supplied static routes
+// into the v2 (Netplan) ethernet devices of the matching interfaces.
+func mergeCustomRoutesIntoNetplanConfig(config *CloudInitNetplanNetwork, routes []customRoute) {
+	byIface := customRoutesByIface(routes)
+	if len(byIface) == 0 {
+		return
+	}
+
+	for name, ifaceRoutes := range byIface {
+		eth, ok := config.Ethernets[name]
+		if !ok {
+			continue
+		}
+
+		for _, route := range ifaceRoutes {
+			eth.Routes = append(eth.Routes, CloudInitNetplanRoute{
+				To:     route.Dst,
+				Via:    route.Gw,
+				Metric: route.Metric,
+			})
+		}
+
+		config.Ethernets[name] = eth
+	}
+}
@@ -0,0 +1,162 @@
+/*
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ *
+ * Copyright 2019 StackPath, LLC
+ *
+ */
+
+// Emits /etc/hosts entries through the cloud-init write_files module instead
+// of the bootcmd shell append previously done in setAdditionalData. The
+// write_files entry itself only needs to run once: it drops an idempotent
+// script under cloud-init's scripts-per-boot directory, which cloud-init
+// re-executes on every boot, so the entries survive a DHCP client rewriting
+// /etc/hosts on a later boot instead of only being correct on first boot.
+
+package main
+
+import (
+	"fmt"
+	"strings"
+
+	"gopkg.in/yaml.v2"
+
+	v1 "kubevirt.io/kubevirt/pkg/api/v1"
+	"kubevirt.io/kubevirt/pkg/log"
+)
+
+// HostsExtra lists additional host aliases to add to the guest's /etc/hosts,
+// formatted as "ip=name[,name...];ip=name[,name...]". Like HostsIpAddress,
+// it is expected to be populated at build time and may be overridden
+// per-VMI via the hostsExtraAnnotation.
+var HostsExtra string
+
+// hostsExtraAnnotation lets an operator supply extra /etc/hosts aliases for
+// a specific VMI, overriding HostsExtra.
+const hostsExtraAnnotation = "sriov-hook.stackpath.com/hosts-extra"
+
+// hostsPerBootScript is where cloud-init's scripts-per-boot module looks for
+// scripts to run on every boot, after networking (and any DHCP-driven
+// /etc/hosts rewrite) is up.
+const hostsPerBootScript = "/var/lib/cloud/scripts/per-boot/91-sriov-hosts"
+
+// hostsMarkerBegin and hostsMarkerEnd bound the block of /etc/hosts this
+// hook owns, so re-running the script replaces only that block instead of
+// appending duplicate entries on every boot.
+const hostsMarkerBegin = "# BEGIN sriov-hook-sidecar managed hosts"
+const hostsMarkerEnd = "# END sriov-hook-sidecar managed hosts"
+
+// CloudInitWriteFile is a representation of a cloud-init write_files entry
+type CloudInitWriteFile struct {
+	Path        string `yaml:"path"`
+	Content     string `yaml:"content"`
+	Permissions string `yaml:"permissions,omitempty"`
+	Append      bool   `yaml:"append,omitempty"`
+}
+
+// CloudInitWriteFiles is a representation of the cloud-init write_files
+// module configuration
+type CloudInitWriteFiles struct {
+	WriteFiles []CloudInitWriteFile `yaml:"write_files"`
+}
+
+// hostsAlias is a single "ip name[ name...]" /etc/hosts entry
+type hostsAlias struct {
+	IP    string
+	Names []string
+}
+
+// parseHostsExtra parses a HostsExtra-style "ip=name[,name...];..." string
+// into hostsAlias entries, skipping malformed groups.
+func parseHostsExtra(raw string) []hostsAlias {
+	var aliases []hostsAlias
+
+	for _, group := range strings.Split(raw, ";") {
+		group = strings.TrimSpace(group)
+		if group == "" {
+			continue
+		}
+
+		parts := strings.SplitN(group, "=", 2)
+		if len(parts) != 2 || parts[0] == "" || parts[1] == "" {
+			log.Log.V(2).Infof("skipping malformed hosts-extra entry: %q", group)
+			continue
+		}
+
+		aliases = append(aliases, hostsAlias{
+			IP:    parts[0],
+			Names: strings.Split(parts[1], ","),
+		})
+	}
+
+	return aliases
+}
+
+// hostsExtra resolves the effective HostsExtra value for a VMI, preferring
+// the per-VMI annotation over the build-time default.
+func hostsExtra(vmi *v1.VirtualMachineInstance) string {
+	if vmi != nil {
+		if extra, ok := vmi.Annotations[hostsExtraAnnotation]; ok && extra != "" {
+			return extra
+		}
+	}
+	return HostsExtra
+}
+
+// shellQuote single-quotes s for safe interpolation into a POSIX shell
+// script, escaping any single quotes it already contains.
+func shellQuote(s string) string {
+	return "'" + strings.ReplaceAll(s, "'", `'\''`) + "'"
+}
+
+// buildHostsScript renders the per-boot script that (re-)writes this hook's
+// managed block of /etc/hosts, replacing any block left by a previous run
+// rather than appending a fresh copy every boot.
+func buildHostsScript(lines []string) string {
+	var script strings.Builder
+	script.WriteString("#!/bin/sh\n")
+	script.WriteString("sed -i '/^" + hostsMarkerBegin + "$/,/^" + hostsMarkerEnd + "$/d' /etc/hosts\n")
+	script.WriteString("{\n")
+	script.WriteString("\techo " + shellQuote(hostsMarkerBegin) + "\n")
+	for _, line := range lines {
+		script.WriteString("\techo " + shellQuote(line) + "\n")
+	}
+	script.WriteString("\techo " + shellQuote(hostsMarkerEnd) + "\n")
+	script.WriteString("} >> /etc/hosts\n")
+	return script.String()
+}
+
+// buildHostsAdditions renders the cloud-config fragment responsible for
+// getting HostsIpAddress (and any extra aliases) into the guest's
+// /etc/hosts. It returns nil if HostsIpAddress isn't set.
+func buildHostsAdditions(vmi *v1.VirtualMachineInstance, hostname string) ([]byte, error) {
+	if len(HostsIpAddress) == 0 {
+		return nil, nil
+	}
+
+	lines := []string{fmt.Sprintf("%s %s", HostsIpAddress, hostname)}
+	for _, alias := range parseHostsExtra(hostsExtra(vmi)) {
+		lines = append(lines, fmt.Sprintf("%s %s", alias.IP, strings.Join(alias.Names, " ")))
+	}
+
+	writeFiles := CloudInitWriteFiles{
+		WriteFiles: []CloudInitWriteFile{
+			{
+				Path:        hostsPerBootScript,
+				Content:     buildHostsScript(lines),
+				Permissions: "0755",
+			},
+		},
+	}
+
+	return yaml.Marshal(writeFiles)
+}
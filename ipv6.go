@@ -0,0 +1,79 @@
+/*
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ *
+ * Copyright 2019 StackPath, LLC
+ *
+ */
+
+// Adds IPv6 discovery on top of the IPv4-only discovery in
+// sriov-discovery.go. network.VIF, a KubeVirt type, only has room for a
+// single address/route family, so sriovVIF wraps it with the IPv6
+// counterparts discovered for the same SR-IOV interface.
+
+package main
+
+import (
+	"net"
+
+	"github.com/vishvananda/netlink"
+
+	"kubevirt.io/kubevirt/pkg/log"
+	"kubevirt.io/kubevirt/pkg/virt-launcher/virtwrap/network"
+)
+
+// IPv6Only and DualStack toggle IPv6 discovery for SR-IOV interfaces. Like
+// HostsIpAddress, they are expected to be populated at build time.
+var IPv6Only string
+var DualStack string
+
+// wantIPv4 reports whether IPv4 addresses/routes should be discovered for
+// SR-IOV interfaces.
+func wantIPv4() bool {
+	return IPv6Only != "true"
+}
+
+// wantIPv6 reports whether IPv6 addresses/routes should be discovered for
+// SR-IOV interfaces.
+func wantIPv6() bool {
+	return IPv6Only == "true" || DualStack == "true"
+}
+
+// sriovVIF extends the upstream network.VIF discovered for a single SR-IOV
+// interface with the IPv6 addressing/routing information network.VIF has no
+// room for.
+type sriovVIF struct {
+	network.VIF
+	IPv6     net.IPNet
+	RoutesV6 *[]netlink.Route
+}
+
+func discoverIPv6(link netlink.Link, vif *sriovVIF) error {
+	addrList, err := network.Handler.AddrList(link, netlink.FAMILY_V6)
+	if err != nil {
+		log.Log.Reason(err).Errorf("failed to get an ipv6 address for %s", vif.Name)
+		return err
+	}
+
+	if len(addrList) > 0 {
+		vif.IPv6 = addrList[0]
+	}
+
+	routes, err := network.Handler.RouteList(link, netlink.FAMILY_V6)
+	if err != nil {
+		log.Log.Reason(err).Errorf("failed to get ipv6 routes for %s", vif.Name)
+		return err
+	}
+	vif.RoutesV6 = &routes
+
+	return nil
+}
@@ -0,0 +1,119 @@
+/*
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ *
+ * Copyright 2019 StackPath, LLC
+ *
+ */
+
+// Deep-merges cloud-config additions into existing userData instead of
+// blindly appending bytes to the end of it. A byte append corrupts
+// userData that already defines one of the keys being added (YAML picks
+// one and drops the other) or that isn't a plain #cloud-config document at
+// all (a MIME multipart archive, #include, or gzip'd payload).
+
+package main
+
+import (
+	"bytes"
+	"fmt"
+
+	"gopkg.in/yaml.v2"
+)
+
+const cloudConfigHeader = "#cloud-config"
+
+// listMergeKeys are cloud-config keys merged by concatenating both sides'
+// lists, so e.g. an existing bootcmd isn't silently replaced by ours.
+var listMergeKeys = map[string]bool{
+	"bootcmd":     true,
+	"runcmd":      true,
+	"write_files": true,
+}
+
+// mapMergeKeys are cloud-config keys merged by overlaying our additions on
+// top of whatever the existing userData already set. manage_resolv_conf is
+// deliberately not here: it marshals as a bare boolean, not a map, so it
+// takes the default scalar-overwrite path alongside it.
+var mapMergeKeys = map[string]bool{
+	"resolv_conf": true,
+}
+
+// mergeCloudConfig deep-merges a #cloud-config additions document into an
+// existing userData document and returns the re-marshaled result with the
+// #cloud-config header restored. existing that isn't a #cloud-config
+// document (no recognizable header, e.g. a MIME multipart archive or
+// #include) is rejected rather than silently corrupted.
+func mergeCloudConfig(existing, additions []byte) ([]byte, error) {
+	if len(additions) == 0 {
+		return existing, nil
+	}
+
+	if len(bytes.TrimSpace(existing)) == 0 {
+		existing = []byte(cloudConfigHeader + "\n")
+	}
+
+	if !bytes.HasPrefix(bytes.TrimSpace(existing), []byte(cloudConfigHeader)) {
+		return existing, fmt.Errorf("cannot merge cloud-config additions into non-cloud-config userData")
+	}
+
+	existingMap := map[string]interface{}{}
+	if err := yaml.Unmarshal(existing, &existingMap); err != nil {
+		return existing, fmt.Errorf("failed to parse existing userData as #cloud-config: %v", err)
+	}
+
+	additionsMap := map[string]interface{}{}
+	if err := yaml.Unmarshal(additions, &additionsMap); err != nil {
+		return existing, fmt.Errorf("failed to parse cloud-config additions: %v", err)
+	}
+
+	for key, value := range additionsMap {
+		switch {
+		case listMergeKeys[key]:
+			existingMap[key] = append(toSlice(existingMap[key]), toSlice(value)...)
+		case mapMergeKeys[key]:
+			existingMap[key] = mergeMaps(toMap(existingMap[key]), toMap(value))
+		default:
+			existingMap[key] = value
+		}
+	}
+
+	merged, err := yaml.Marshal(existingMap)
+	if err != nil {
+		return existing, fmt.Errorf("failed to marshal merged userData: %v", err)
+	}
+
+	return append([]byte(cloudConfigHeader+"\n"), merged...), nil
+}
+
+func toSlice(v interface{}) []interface{} {
+	s, _ := v.([]interface{})
+	return s
+}
+
+func toMap(v interface{}) map[interface{}]interface{} {
+	if m, ok := v.(map[interface{}]interface{}); ok {
+		return m
+	}
+	return map[interface{}]interface{}{}
+}
+
+func mergeMaps(base, overlay map[interface{}]interface{}) map[interface{}]interface{} {
+	merged := map[interface{}]interface{}{}
+	for k, v := range base {
+		merged[k] = v
+	}
+	for k, v := range overlay {
+		merged[k] = v
+	}
+	return merged
+}
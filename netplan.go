@@ -0,0 +1,160 @@
+/*
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ *
+ * Copyright 2019 StackPath, LLC
+ *
+ */
+
+// Adds support for emitting cloud-init network-config v2 (Netplan) in
+// addition to the v1 schema handled in sriov-discovery.go. Netplan is the
+// only schema Ubuntu >=18.04 guests fully consume, so SR-IOV interfaces on
+// those images need DNS/route handling that v1 cannot express.
+
+package main
+
+import (
+	"net"
+	"strings"
+
+	"github.com/vishvananda/netlink"
+
+	"kubevirt.io/kubevirt/pkg/api/v1"
+)
+
+// networkVersionAnnotation lets an operator pin the cloud-init network-config
+// schema version for a specific VMI, overriding CloudInitNetworkVersion.
+const networkVersionAnnotation = "sriov-hook.stackpath.com/network-version"
+
+// CloudInitNetworkVersion selects the cloud-init network-config schema
+// version ("1" or "2") discovered SR-IOV interfaces are emitted as. Like
+// HostsIpAddress, it is expected to be populated at build time and defaults
+// to v1 when unset.
+var CloudInitNetworkVersion string
+
+// networkConfigVersion resolves the effective network-config version for a
+// VMI, preferring the per-VMI annotation over CloudInitNetworkVersion and
+// falling back to v1.
+func networkConfigVersion(vmi *v1.VirtualMachineInstance) string {
+	if vmi != nil {
+		if version, ok := vmi.Annotations[networkVersionAnnotation]; ok && version != "" {
+			return version
+		}
+	}
+	if CloudInitNetworkVersion != "" {
+		return CloudInitNetworkVersion
+	}
+	return "1"
+}
+
+// CloudInitNetplanMatch is a representation of a cloud-init network config v2
+// (Netplan) device match object
+type CloudInitNetplanMatch struct {
+	MacAddress string `yaml:"macaddress,omitempty"`
+}
+
+// CloudInitNetplanRoute is a representation of a cloud-init network config v2
+// (Netplan) route object
+type CloudInitNetplanRoute struct {
+	To     string `yaml:"to,omitempty"`
+	Via    string `yaml:"via,omitempty"`
+	Metric int    `yaml:"metric,omitempty"`
+}
+
+// CloudInitNetplanNameservers is a representation of a cloud-init network
+// config v2 (Netplan) nameservers object
+type CloudInitNetplanNameservers struct {
+	Addresses []string `yaml:"addresses,omitempty"`
+	Search    []string `yaml:"search,omitempty"`
+}
+
+// CloudInitNetplanEthernet is a representation of a cloud-init network
+// config v2 (Netplan) ethernet device object
+type CloudInitNetplanEthernet struct {
+	Match       CloudInitNetplanMatch       `yaml:"match,omitempty"`
+	SetName     string                      `yaml:"set-name,omitempty"`
+	Mtu         uint16                      `yaml:"mtu,omitempty"`
+	Addresses   []string                    `yaml:"addresses,omitempty"`
+	Gateway4    string                      `yaml:"gateway4,omitempty"`
+	Gateway6    string                      `yaml:"gateway6,omitempty"`
+	Routes      []CloudInitNetplanRoute     `yaml:"routes,omitempty"`
+	Nameservers CloudInitNetplanNameservers `yaml:"nameservers,omitempty"`
+}
+
+// CloudInitNetplanNetwork is a representation of the "network" key of a
+// cloud-init network config v2 (Netplan) document
+type CloudInitNetplanNetwork struct {
+	Version   int                                 `yaml:"version"`
+	Ethernets map[string]CloudInitNetplanEthernet `yaml:"ethernets,omitempty"`
+}
+
+// CloudInitNetplanConfig is a representation of a cloud-init network config v2
+// (Netplan) document
+type CloudInitNetplanConfig struct {
+	Network CloudInitNetplanNetwork `yaml:"network"`
+}
+
+func addNetplanAddress(eth *CloudInitNetplanEthernet, address net.IPNet, routes *[]netlink.Route, isV6 bool) {
+	eth.Addresses = append(eth.Addresses, address.String())
+
+	if routes == nil {
+		return
+	}
+
+	for _, route := range *routes {
+		if route.Dst == nil && route.Src.Equal(nil) && route.Gw.Equal(nil) {
+			continue
+		}
+		if route.Src != nil && route.Src.Equal(address.IP) {
+			continue
+		}
+		if route.Dst == nil {
+			if isV6 {
+				eth.Gateway6 = route.Gw.String()
+			} else {
+				eth.Gateway4 = route.Gw.String()
+			}
+			continue
+		}
+
+		eth.Routes = append(eth.Routes, CloudInitNetplanRoute{
+			To:  strings.Split(route.Dst.String(), " ")[0],
+			Via: route.Gw.String(),
+		})
+	}
+}
+
+func convertCloudInitNetworksToCloudInitNetplanConfig(cloudInitNetworks *[]sriovVIF, config *CloudInitNetplanNetwork) {
+	if config.Ethernets == nil {
+		config.Ethernets = map[string]CloudInitNetplanEthernet{}
+	}
+
+	for _, vif := range *cloudInitNetworks {
+		eth := CloudInitNetplanEthernet{
+			SetName: vif.Name,
+			Mtu:     vif.Mtu,
+			Match: CloudInitNetplanMatch{
+				MacAddress: vif.MAC.String(),
+			},
+		}
+
+		if vif.IP.String() != "<nil>" {
+			addNetplanAddress(&eth, vif.IP, vif.Routes, false)
+		}
+
+		if vif.IPv6.String() != "<nil>" {
+			addNetplanAddress(&eth, vif.IPv6, vif.RoutesV6, true)
+		}
+
+		config.Ethernets[vif.Name] = eth
+	}
+}
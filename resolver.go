@@ -0,0 +1,156 @@
+/*
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ *
+ * Copyright 2019 StackPath, LLC
+ *
+ */
+
+// Resolves the in-container interface name SR-IOV discovery should operate
+// on for a given VMI network attachment. getSriovNetworkInfo used to guess
+// this purely from CNI type and attachment order (eth0/net1/net2/...),
+// which breaks once Multus reorders attachments or a NetworkAttachmentDefinition
+// requests a specific ifname. NetworkNameResolver lets each CNI (or the
+// network-status annotation, when present) answer authoritatively.
+
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	v1 "kubevirt.io/kubevirt/pkg/api/v1"
+)
+
+// networkStatusAnnotation is the annotation Multus populates with the actual
+// in-container interface name of every attached network, keyed by
+// NetworkAttachmentDefinition name. It is read from the VMI's own
+// annotations only; no downward-API-mounted file is consulted.
+const networkStatusAnnotation = "k8s.v1.cni.cncf.io/network-status"
+
+// networkStatusEntry is a single entry of the network-status annotation.
+type networkStatusEntry struct {
+	Name      string   `json:"name"`
+	Interface string   `json:"interface"`
+	IPs       []string `json:"ips,omitempty"`
+	Default   bool     `json:"default,omitempty"`
+}
+
+// parseNetworkStatus parses the networkStatusAnnotation off of a VMI,
+// returning no entries if it isn't set.
+func parseNetworkStatus(vmi *v1.VirtualMachineInstance) ([]networkStatusEntry, error) {
+	raw, ok := vmi.Annotations[networkStatusAnnotation]
+	if !ok || raw == "" {
+		return nil, nil
+	}
+
+	var entries []networkStatusEntry
+	if err := json.Unmarshal([]byte(raw), &entries); err != nil {
+		return nil, fmt.Errorf("failed to parse %s annotation: %v", networkStatusAnnotation, err)
+	}
+
+	return entries, nil
+}
+
+// NetworkNameResolver maps a VMI network attachment to the in-container
+// interface name SR-IOV discovery should operate on. ok is false if the
+// resolver has no opinion on this attachment.
+type NetworkNameResolver interface {
+	ResolveIfaceName(net *v1.Network, netName string, index int) (ifaceName string, ok bool)
+}
+
+// networkStatusResolver resolves interface names from the network-status
+// annotation, which reflects what the CNI plugin actually assigned rather
+// than a guess from attachment order. It is authoritative for any CNI that
+// publishes it, including ovn4nfv/kube-ovn attachments that use in-container
+// device naming conventions multusResolver/genieResolver don't know about.
+type networkStatusResolver struct {
+	entries []networkStatusEntry
+}
+
+func (r networkStatusResolver) ResolveIfaceName(net *v1.Network, netName string, index int) (string, bool) {
+	candidates := []string{netName}
+	if net.Multus != nil && net.Multus.NetworkName != "" {
+		candidates = append(candidates, net.Multus.NetworkName)
+	}
+
+	for _, entry := range r.entries {
+		name := entry.Name
+		if slash := strings.LastIndex(name, "/"); slash != -1 {
+			name = name[slash+1:]
+		}
+		for _, candidate := range candidates {
+			if name == candidate {
+				return entry.Interface, true
+			}
+		}
+	}
+
+	return "", false
+}
+
+// multusResolver reproduces the historical Multus naming convention: the
+// default network is eth0, non-default attachments are net<index>.
+type multusResolver struct{}
+
+func (multusResolver) ResolveIfaceName(net *v1.Network, netName string, index int) (string, bool) {
+	if net.Multus == nil {
+		return "", false
+	}
+	if net.Multus.Default {
+		return "eth0", true
+	}
+	return fmt.Sprintf("net%d", index), true
+}
+
+// genieResolver reproduces the historical Genie naming convention: every
+// attachment is eth<index>.
+type genieResolver struct{}
+
+func (genieResolver) ResolveIfaceName(net *v1.Network, netName string, index int) (string, bool) {
+	if net.Genie == nil {
+		return "", false
+	}
+	return fmt.Sprintf("eth%d", index), true
+}
+
+// networkNameResolvers builds the resolver chain for a VMI, in priority
+// order. The network-status resolver goes first since it reflects ground
+// truth; the per-CNI resolvers are best-effort fallbacks for when it isn't
+// published.
+func networkNameResolvers(vmi *v1.VirtualMachineInstance) ([]NetworkNameResolver, error) {
+	var resolvers []NetworkNameResolver
+
+	entries, err := parseNetworkStatus(vmi)
+	if err != nil {
+		return nil, err
+	}
+	if len(entries) > 0 {
+		resolvers = append(resolvers, networkStatusResolver{entries: entries})
+	}
+
+	resolvers = append(resolvers, multusResolver{}, genieResolver{})
+
+	return resolvers, nil
+}
+
+// resolveIfaceName runs a network attachment through the resolver chain,
+// returning the first resolver's answer.
+func resolveIfaceName(resolvers []NetworkNameResolver, net *v1.Network, netName string, index int) (string, bool) {
+	for _, resolver := range resolvers {
+		if name, ok := resolver.ResolveIfaceName(net, netName, index); ok {
+			return name, true
+		}
+	}
+	return "", false
+}
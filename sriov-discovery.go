@@ -122,11 +122,16 @@ func setNetworkInfo(vmi *v1.VirtualMachineInstance) (map[string]*v1.Network, map
 	return networks, cniNetworks
 }
 
-func getSriovNetworkInfo(vmi *v1.VirtualMachineInstance) ([]network.VIF, error) {
-	var sriovVifs []network.VIF
+func getSriovNetworkInfo(vmi *v1.VirtualMachineInstance) ([]sriovVIF, error) {
+	var sriovVifs []sriovVIF
 
 	networks, cniNetworks := setNetworkInfo(vmi)
 
+	resolvers, err := networkNameResolvers(vmi)
+	if err != nil {
+		return sriovVifs, err
+	}
+
 	for _, iface := range vmi.Spec.Domain.Devices.Interfaces {
 		net, isExist := networks[iface.Name]
 		if !isExist {
@@ -137,28 +142,26 @@ func getSriovNetworkInfo(vmi *v1.VirtualMachineInstance) ([]network.VIF, error)
 			disableResolv = true
 		}
 
-		if value, ok := cniNetworks[iface.Name]; ok {
-			prefix := ""
-			// no error check, we assume that CNI type was set correctly
-			if net.Multus != nil {
-				if net.Multus.Default {
-					// Default network is eth0
-					prefix = "eth"
-				} else {
-					prefix = "net"
-				}
-			} else if net.Genie != nil {
-				prefix = "eth"
-			}
-			if iface.SRIOV != nil {
-				details, err := getNetworkDetails(fmt.Sprintf("%s%d", prefix, value))
-				if err != nil {
-					log.Log.Reason(err).Errorf("failed to get SR-IOV network details for %s", fmt.Sprintf("%s%d", prefix, value))
-					return sriovVifs, err
-				}
-				sriovVifs = append(sriovVifs, details)
-			}
+		if iface.SRIOV == nil {
+			continue
+		}
+
+		index, ok := cniNetworks[iface.Name]
+		if !ok {
+			continue
+		}
+
+		ifaceName, ok := resolveIfaceName(resolvers, net, iface.Name, index)
+		if !ok {
+			return sriovVifs, fmt.Errorf("failed to resolve in-container interface name for network %s", iface.Name)
 		}
+
+		details, err := getNetworkDetails(ifaceName)
+		if err != nil {
+			log.Log.Reason(err).Errorf("failed to get SR-IOV network details for %s", ifaceName)
+			return sriovVifs, err
+		}
+		sriovVifs = append(sriovVifs, details)
 	}
 	if len(sriovVifs) == 0 {
 		err := fmt.Errorf("No SRIOV interfaces found")
@@ -168,13 +171,13 @@ func getSriovNetworkInfo(vmi *v1.VirtualMachineInstance) ([]network.VIF, error)
 }
 
 // Scavenged from various parts of podnetwork and BridgePodInterface
-func getNetworkDetails(intName string) (network.VIF, error) {
+func getNetworkDetails(intName string) (sriovVIF, error) {
 	log.Log.V(2).Infof("starting discovery for: %s", intName)
 	if network.Handler == nil {
 		network.Handler = &network.NetworkUtilsHandler{}
 	}
 
-	var vif network.VIF
+	var vif sriovVIF
 
 	vif.Name = intName
 
@@ -184,14 +187,29 @@ func getNetworkDetails(intName string) (network.VIF, error) {
 		return vif, err
 	}
 
-	addrList, err := network.Handler.AddrList(link, netlink.FAMILY_V4)
-	if err != nil {
-		log.Log.Reason(err).Errorf("failed to get an ip address for %s", vif.Name)
-		return vif, err
+	if wantIPv4() {
+		addrList, err := network.Handler.AddrList(link, netlink.FAMILY_V4)
+		if err != nil {
+			log.Log.Reason(err).Errorf("failed to get an ip address for %s", vif.Name)
+			return vif, err
+		}
+
+		if len(addrList) > 0 {
+			vif.IP = addrList[0]
+		}
+
+		routes, err := network.Handler.RouteList(link, netlink.FAMILY_V4)
+		if err != nil {
+			log.Log.Reason(err).Errorf("failed to get routes for %s", vif.Name)
+			return vif, err
+		}
+		vif.Routes = &routes
 	}
 
-	if len(addrList) > 0 {
-		vif.IP = addrList[0]
+	if wantIPv6() {
+		if err := discoverIPv6(link, &vif); err != nil {
+			return vif, err
+		}
 	}
 
 	if len(vif.MAC) == 0 {
@@ -203,13 +221,6 @@ func getNetworkDetails(intName string) (network.VIF, error) {
 		vif.MAC = mac
 	}
 
-	routes, err := network.Handler.RouteList(link, netlink.FAMILY_V4)
-	if err != nil {
-		log.Log.Reason(err).Errorf("failed to get routes for %s", vif.Name)
-		return vif, err
-	}
-	vif.Routes = &routes
-
 	vif.Mtu = uint16(link.Attrs().MTU)
 
 	return vif, nil
@@ -246,51 +257,69 @@ func getCloudInitManageResolv() (CloudInitManageResolv, error) {
 	return cloudInitManageResolv, nil
 }
 
-func convertCloudInitNetworksToCloudInitNetConfig(cloudInitNetworks *[]network.VIF, config *CloudInitNetConfig) {
+// buildCloudInitSubnet builds a v1 cloud-init subnet from a discovered
+// address and its associated routes, skipping the interface's own connected
+// route and attributing the default route to the subnet gateway.
+func buildCloudInitSubnet(subnetType string, address net.IPNet, routes *[]netlink.Route) CloudInitSubnet {
+	nifSubnet := CloudInitSubnet{
+		SubnetType: subnetType,
+		Address:    strings.Split(address.String(), " ")[0],
+	}
+
+	if routes == nil {
+		return nifSubnet
+	}
+
+	var nifRoutes []CloudInitSubnetRoute
+	for _, route := range *routes {
+		if route.Dst == nil && route.Src.Equal(nil) && route.Gw.Equal(nil) {
+			continue
+		}
+
+		if route.Src != nil && route.Src.Equal(address.IP) {
+			continue
+		}
+
+		if route.Dst == nil {
+			nifSubnet.Gateway = route.Gw.String()
+			continue
+		}
+
+		subnetRoute := CloudInitSubnetRoute{
+			Network: route.Dst.IP.String(),
+			Netmask: net.IP(route.Dst.Mask).String(),
+		}
+		if route.Gw != nil {
+			subnetRoute.Gateway = route.Gw.String()
+		}
+		nifRoutes = append(nifRoutes, subnetRoute)
+	}
+	nifSubnet.Routes = nifRoutes
+
+	return nifSubnet
+}
+
+func convertCloudInitNetworksToCloudInitNetConfig(cloudInitNetworks *[]sriovVIF, config *CloudInitNetConfig) {
 	for _, vif := range *cloudInitNetworks {
 		var nif CloudInitNetworkInterface
-		var nifSubnet CloudInitSubnet
-		var nifRoutes []CloudInitSubnetRoute
 
 		nif.Name = vif.Name
 		nif.NetworkType = "physical"
 		nif.MacAddress = vif.MAC.String()
 		nif.Mtu = vif.Mtu
 
-		if vif.IP.String() == "<nil>" {
-			nifSubnet.SubnetType = "manual"
-			nif.Subnets = append(nif.Subnets, nifSubnet)
-		} else {
-			nifSubnet.SubnetType = "static"
-			nifSubnet.Address = strings.Split(vif.IP.String(), " ")[0]
-			for _, route := range *vif.Routes {
-				if route.Dst == nil && route.Src.Equal(nil) && route.Gw.Equal(nil) {
-					continue
-				}
-
-				if route.Src != nil && route.Src.Equal(vif.IP.IP) {
-					continue
-				}
-
-				var subnetRoute CloudInitSubnetRoute
-
-				if route.Dst == nil {
-					nifSubnet.Gateway = route.Gw.String()
-					continue
-				} else {
-					subnetRoute.Network = route.Dst.IP.String()
-				}
-
-				subnetRoute.Network = route.Dst.IP.String()
-				subnetRoute.Netmask = net.IP(route.Dst.Mask).String()
-				if route.Gw != nil {
-					subnetRoute.Gateway = route.Gw.String()
-				}
-				nifRoutes = append(nifRoutes, subnetRoute)
-			}
-			nifSubnet.Routes = nifRoutes
-			nif.Subnets = append(nif.Subnets, nifSubnet)
+		if vif.IP.String() != "<nil>" {
+			nif.Subnets = append(nif.Subnets, buildCloudInitSubnet("static", vif.IP, vif.Routes))
+		}
+
+		if vif.IPv6.String() != "<nil>" {
+			nif.Subnets = append(nif.Subnets, buildCloudInitSubnet("static6", vif.IPv6, vif.RoutesV6))
+		}
+
+		if len(nif.Subnets) == 0 {
+			nif.Subnets = append(nif.Subnets, CloudInitSubnet{SubnetType: "manual"})
 		}
+
 		config.Config = append(config.Config, nif)
 	}
 }
@@ -298,7 +327,7 @@ func convertCloudInitNetworksToCloudInitNetConfig(cloudInitNetworks *[]network.V
 func cloudInitDiscoverNetworkData(vmi *v1.VirtualMachineInstance) ([]byte, []byte, error) {
 	var networkFile []byte
 	var resolvFile []byte
-	var cloudInitNetworks []network.VIF
+	var cloudInitNetworks []sriovVIF
 
 	cloudInitNetworks, err := getSriovNetworkInfo(vmi)
 	if err != nil {
@@ -309,15 +338,39 @@ func cloudInitDiscoverNetworkData(vmi *v1.VirtualMachineInstance) ([]byte, []byt
 		return networkFile, resolvFile, err
 	}
 
-	var config = CloudInitNetConfig{
-		Version: 1,
+	customRoutes, err := getCustomRoutes(vmi)
+	if err != nil {
+		return networkFile, resolvFile, err
 	}
 
-	convertCloudInitNetworksToCloudInitNetConfig(&cloudInitNetworks, &config)
+	if networkConfigVersion(vmi) == "2" {
+		var netplanConfig = CloudInitNetplanConfig{
+			Network: CloudInitNetplanNetwork{
+				Version: 2,
+			},
+		}
 
-	networkFile, err = yaml.Marshal(config)
-	if err != nil {
-		return networkFile, resolvFile, err
+		convertCloudInitNetworksToCloudInitNetplanConfig(&cloudInitNetworks, &netplanConfig.Network)
+		mergeCustomRoutesIntoNetplanConfig(&netplanConfig.Network, customRoutes)
+
+		networkFile, err = yaml.Marshal(netplanConfig)
+		if err != nil {
+			return networkFile, resolvFile, err
+		}
+	} else {
+		var config = CloudInitNetConfig{
+			Version: 1,
+		}
+
+		convertCloudInitNetworksToCloudInitNetConfig(&cloudInitNetworks, &config)
+		if err := mergeCustomRoutesIntoNetConfig(&config, customRoutes); err != nil {
+			return networkFile, resolvFile, err
+		}
+
+		networkFile, err = yaml.Marshal(config)
+		if err != nil {
+			return networkFile, resolvFile, err
+		}
 	}
 
 	cloudInitManageResolv, err := getCloudInitManageResolv()